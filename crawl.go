@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// A crawlJob represents a single page queued for fetching, along with
+// how many hops it is from one of the original seed URLs and, when
+// -same-host is set, the host of the seed URL it descends from (so
+// links found on one seed's pages aren't filtered against a different
+// seed's host).
+type crawlJob struct {
+	link  string
+	depth uint
+	host  string
+}
+
+// A crawler holds the state shared across worker goroutines for a single
+// run: the search word, the crawl limits, and the set of pages already
+// dispatched so that no page is counted twice.
+type crawler struct {
+	ctx context.Context
+	cfg *fetchConfig
+
+	matcher    Matcher         // used unless wordlist is set
+	wordlist   map[string]uint // non-nil when running in -wordlist mode
+	ignoreCase bool            // fold case when matching in -wordlist mode
+	htmlMode   string          // one of "auto", "on", "off"
+	maxDepth   uint
+	sameHost   bool
+
+	mu      sync.Mutex
+	visited map[string]bool
+	wg      sync.WaitGroup
+}
+
+// newCrawler builds a crawler ready to have seed links submitted to it.
+func newCrawler(ctx context.Context, cfg *fetchConfig, matcher Matcher, wordlist map[string]uint, ignoreCase bool, htmlMode string, maxDepth uint, sameHost bool) *crawler {
+	return &crawler{
+		ctx:        ctx,
+		cfg:        cfg,
+		matcher:    matcher,
+		wordlist:   wordlist,
+		ignoreCase: ignoreCase,
+		htmlMode:   htmlMode,
+		maxDepth:   maxDepth,
+		sameHost:   sameHost,
+		visited:    make(map[string]bool),
+	}
+}
+
+// markVisited records link as visited, reporting whether it had already
+// been seen. Safe for concurrent use.
+func (c *crawler) markVisited(link string) (alreadySeen bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.visited[link] {
+		return true
+	}
+	c.visited[link] = true
+	return false
+}
+
+// submit enqueues a job on jobs, registering it with the crawler's
+// WaitGroup first so that the dispatcher doesn't close the channel out
+// from under it. It is sent from a fresh goroutine so that a full
+// channel never blocks the worker that discovered the link.
+func (c *crawler) submit(jobs chan crawlJob, job crawlJob) {
+	c.wg.Add(1)
+	go func() { jobs <- job }()
+}
+
+// run drains jobs with numWorkers workers, closing results once every
+// submitted job (including those discovered along the way) has been
+// processed.
+func (c *crawler) run(jobs chan crawlJob, results chan Result, numWorkers uint) {
+	for i := uint(0); i < numWorkers; i++ {
+		go c.worker(jobs, results)
+	}
+
+	// No more jobs can ever be submitted once the WaitGroup drains, so
+	// it's safe to close the jobs channel and let the workers exit.
+	go func() {
+		c.wg.Wait()
+		close(jobs)
+		close(results)
+	}()
+}
+
+// worker processes jobs until the jobs channel is closed.
+func (c *crawler) worker(jobs chan crawlJob, results chan Result) {
+	for job := range jobs {
+		c.visit(job, jobs, results)
+	}
+}
+
+// visit fetches a single job, reports its Result, and (if the depth
+// budget allows) queues any newly discovered, not-yet-visited links.
+func (c *crawler) visit(job crawlJob, jobs chan crawlJob, results chan Result) {
+	defer c.wg.Done()
+
+	count, counts, links, err := fetchAndScan(c.ctx, c.cfg, c.matcher, c.wordlist, c.ignoreCase, c.htmlMode, job.link)
+	results <- Result{Link: job.link, Count: count, Counts: counts, Err: err}
+	if err != nil || job.depth >= c.maxDepth {
+		return
+	}
+
+	for _, link := range links {
+		if c.sameHost && link.Host != job.host {
+			continue
+		}
+		linkStr := link.String()
+		if c.markVisited(linkStr) {
+			continue
+		}
+		c.submit(jobs, crawlJob{linkStr, job.depth + 1, job.host})
+	}
+}
+
+// extractLinks parses an HTML page and returns the resolved, absolute
+// URLs referenced by every <a href> it contains. base is the URL the
+// page was fetched from, used to resolve relative links. The fragment of
+// each resolved URL is cleared, since it isn't sent to the server and a
+// page differing only by fragment (e.g. "#top") is the same fetch.
+func extractLinks(base *url.URL, body []byte) ([]*url.URL, error) {
+	var links []*url.URL
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data != "a" {
+				continue
+			}
+			for _, attr := range token.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				ref, err := url.Parse(attr.Val)
+				if err != nil {
+					continue
+				}
+				resolved := base.ResolveReference(ref)
+				resolved.Fragment = ""
+				links = append(links, resolved)
+			}
+		}
+	}
+}
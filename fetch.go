@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// netOptions holds the raw values of the networking-tuning flags
+// (-delay, -rps, -per-host-rps, -retries, -timeout) as parsed from the
+// CLI, before they're turned into a fetchConfig.
+type netOptions struct {
+	delay      time.Duration
+	rps        float64
+	perHostRPS float64
+	retries    uint
+	timeout    time.Duration
+}
+
+// fetchConfig bundles the networking flags (-delay, -rps, -per-host-rps,
+// -retries, -timeout) so they can be threaded through the crawler
+// without a long parameter list.
+type fetchConfig struct {
+	client  *http.Client
+	delay   time.Duration
+	retries uint
+	global  *rate.Limiter
+	perHost *hostLimiters
+}
+
+// newFetchConfig builds a fetchConfig from the corresponding CLI flags.
+// A zero value for rps or perHostRPS disables that limiter.
+func newFetchConfig(timeout, delay time.Duration, retries uint, rps, perHostRPS float64) *fetchConfig {
+	return &fetchConfig{
+		client:  &http.Client{Timeout: timeout},
+		delay:   delay,
+		retries: retries,
+		global:  newLimiter(rps),
+		perHost: newHostLimiters(perHostRPS),
+	}
+}
+
+// wait blocks until host is clear to fetch under both the global and
+// per-host rate limits, then applies the fixed per-request delay.
+func (f *fetchConfig) wait(ctx context.Context, host string) error {
+	if f.global != nil {
+		if err := f.global.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l := f.perHost.forHost(host); l != nil {
+		if err := l.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// get performs an HTTP GET for link, retrying on 5xx responses and
+// timeouts with exponential backoff. It gives up after f.retries retries.
+func (f *fetchConfig) get(ctx context.Context, link string) (*http.Response, error) {
+	var lastErr error
+	for attempt := uint(0); attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := f.client.Do(req)
+		if err != nil {
+			lastErr = err
+			if isTimeout(err) {
+				continue
+			}
+			return nil, err
+		}
+		if res.StatusCode >= 500 {
+			lastErr = fmt.Errorf("received %s", res.Status)
+			res.Body.Close()
+			continue
+		}
+		return res, nil
+	}
+	return nil, lastErr
+}
+
+// backoff returns the delay to wait before retry attempt n (1-indexed),
+// doubling from 100ms.
+func backoff(attempt uint) time.Duration {
+	return time.Duration(math.Pow(2, float64(attempt-1))) * 100 * time.Millisecond
+}
+
+// isTimeout reports whether err represents a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// fetchAndScan fetches link (subject to cfg's rate limits, retries, and
+// timeout) and extracts any links the page contains for further
+// crawling. If wordlist is non-nil, every word in it is counted in a
+// single pass over the body (folding case first if ignoreCase is set);
+// otherwise matcher alone is applied. Per htmlMode (see
+// shouldTokenizeHTML), the body is reduced to its visible text before
+// matching, so that <script>/<style> contents, tag names, and attribute
+// values aren't mistaken for page text.
+func fetchAndScan(ctx context.Context, cfg *fetchConfig, matcher Matcher, wordlist map[string]uint, ignoreCase bool, htmlMode string, link string) (uint, map[string]uint, []*url.URL, error) {
+	target, err := url.Parse(link)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	if err := cfg.wait(ctx, target.Host); err != nil {
+		return 0, nil, nil, err
+	}
+
+	res, err := cfg.get(ctx, link)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return 0, nil, nil, errors.New("Did not receive 200 OK")
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	scanBody := body
+	if shouldTokenizeHTML(htmlMode, res.Header.Get("Content-Type")) {
+		scanBody = visibleText(body)
+	}
+
+	var count uint
+	var counts map[string]uint
+	if wordlist != nil {
+		counts, err = countOccurrencesMulti(wordlist, bytes.NewReader(scanBody), ignoreCase)
+	} else {
+		count, err = matcher.Count(bytes.NewReader(scanBody))
+	}
+	if err != nil {
+		return count, counts, nil, err
+	}
+
+	links, err := extractLinks(res.Request.URL, body)
+	return count, counts, links, err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// shouldTokenizeHTML decides, given the -html mode and a response's
+// Content-Type, whether the body should be run through the HTML
+// tokenizer before matching. "on" always does; "off" never does (the
+// byte-accurate scanner mode, useful for benchmarking); "auto" tokenizes
+// text/html responses and falls back to the raw scanner for everything
+// else, such as text/plain.
+func shouldTokenizeHTML(htmlMode string, contentType string) bool {
+	switch htmlMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	default:
+		return strings.Contains(contentType, "text/html")
+	}
+}
+
+// visibleText extracts the human-visible text of an HTML document,
+// skipping the contents of <script>, <style>, and comments, and
+// decoding entities along the way.
+func visibleText(body []byte) []byte {
+	var buf bytes.Buffer
+	skipDepth := 0
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return buf.Bytes()
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); skipsContent(string(name)) {
+				skipDepth++
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); skipsContent(string(name)) && skipDepth > 0 {
+				skipDepth--
+			}
+		case html.TextToken:
+			if skipDepth == 0 {
+				buf.Write(tokenizer.Text())
+				buf.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// skipsContent reports whether text inside a tag with this name should
+// be excluded from matching.
+func skipsContent(tag string) bool {
+	return tag == "script" || tag == "style"
+}
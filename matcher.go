@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Matcher counts how many times a target pattern occurs in a stream of
+// text. Abstracting over it lets the worker code stay the same whether
+// it's doing an exact word match or a regex match.
+type Matcher interface {
+	Count(io.Reader) (uint, error)
+}
+
+// wordMatcher counts exact occurrences of a single word, optionally
+// folding case.
+type wordMatcher struct {
+	word       string
+	ignoreCase bool
+}
+
+// Count implements Matcher.
+func (m wordMatcher) Count(s io.Reader) (uint, error) {
+	target := m.word
+	if m.ignoreCase {
+		target = strings.ToLower(target)
+	}
+
+	scanner := bufio.NewScanner(s)
+	scanner.Split(bufio.ScanWords)
+
+	var count uint
+	for scanner.Scan() {
+		token := scanner.Text()
+		if m.ignoreCase {
+			token = strings.ToLower(token)
+		}
+		if token == target {
+			count++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// regexMatcher counts non-overlapping matches of a compiled regular
+// expression against the whole body, which lets a pattern span or match
+// inside what bufio.ScanWords would treat as a single token.
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+// Count implements Matcher.
+func (m regexMatcher) Count(s io.Reader) (uint, error) {
+	body, err := io.ReadAll(s)
+	if err != nil {
+		return 0, err
+	}
+	return uint(len(m.re.FindAllIndex(body, -1))), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// A ResultWriter formats Results as they're produced and writes them to
+// some underlying destination.
+type ResultWriter interface {
+	Write(Result) error
+	Close() error
+}
+
+// newResultWriter builds the ResultWriter for the given -format, writing
+// to out.
+func newResultWriter(format string, out io.Writer) (ResultWriter, error) {
+	switch format {
+	case "text", "":
+		return newTextWriter(out), nil
+	case "json":
+		return &jsonWriter{out: out}, nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(out)}, nil
+	case "csv":
+		return newDelimitedWriter(out, ','), nil
+	case "tsv":
+		return newDelimitedWriter(out, '\t'), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// sortedHits returns the words in counts with a non-zero count, sorted
+// for stable output.
+func sortedHits(counts map[string]uint) []string {
+	words := make([]string, 0, len(counts))
+	for word, count := range counts {
+		if count > 0 {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// textWriter renders Results as a tabwriter-aligned table.
+type textWriter struct {
+	tw *tabwriter.Writer
+}
+
+func newTextWriter(out io.Writer) *textWriter {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINK\tWORD\tCOUNT\tERROR")
+	return &textWriter{tw: tw}
+}
+
+func (w *textWriter) Write(r Result) error {
+	if r.Counts != nil {
+		for _, word := range sortedHits(r.Counts) {
+			fmt.Fprintf(w.tw, "%s\t%s\t%d\t\n", r.Link, word, r.Counts[word])
+		}
+		if r.Err != nil {
+			fmt.Fprintf(w.tw, "%s\t\t\t%v\n", r.Link, r.Err)
+		}
+		return nil
+	}
+	fmt.Fprintf(w.tw, "%s\t\t%d\t%v\n", r.Link, r.Count, r.Err)
+	return nil
+}
+
+func (w *textWriter) Close() error {
+	return w.tw.Flush()
+}
+
+// jsonWriter collects every Result and writes them as a single JSON
+// array on Close, since a JSON array can't be streamed a line at a time.
+type jsonWriter struct {
+	out     io.Writer
+	results []Result
+}
+
+func (w *jsonWriter) Write(r Result) error {
+	w.results = append(w.results, r)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	enc := json.NewEncoder(w.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(w.results)
+}
+
+// ndjsonWriter writes one JSON object per Result, per line, as soon as
+// each one is produced.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(r Result) error {
+	return w.enc.Encode(r)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// delimitedWriter renders Results as delimited rows (csv or tsv,
+// depending on the delimiter it's built with).
+type delimitedWriter struct {
+	w *csv.Writer
+}
+
+func newDelimitedWriter(out io.Writer, delim rune) *delimitedWriter {
+	w := csv.NewWriter(out)
+	w.Comma = delim
+	w.Write([]string{"link", "word", "count", "error"})
+	return &delimitedWriter{w: w}
+}
+
+func (w *delimitedWriter) Write(r Result) error {
+	errStr := ""
+	if r.Err != nil {
+		errStr = r.Err.Error()
+	}
+
+	if r.Counts != nil {
+		for _, word := range sortedHits(r.Counts) {
+			if err := w.w.Write([]string{r.Link, word, fmt.Sprint(r.Counts[word]), ""}); err != nil {
+				return err
+			}
+		}
+		if r.Err != nil {
+			return w.w.Write([]string{r.Link, "", "", errStr})
+		}
+		return nil
+	}
+
+	return w.w.Write([]string{r.Link, "", fmt.Sprint(r.Count), errStr})
+}
+
+func (w *delimitedWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// newLimiter returns a token-bucket limiter allowing rps requests per
+// second, or nil if rps is 0 (unlimited).
+func newLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(rps), 1)
+}
+
+// hostLimiters lazily creates and caches a rate.Limiter per host, so that
+// each origin is paced independently and one slow host can't eat into
+// another's budget.
+type hostLimiters struct {
+	rps float64 // 0 disables per-host limiting
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// newHostLimiters builds a hostLimiters that caps each distinct host to
+// rps requests per second.
+func newHostLimiters(rps float64) *hostLimiters {
+	return &hostLimiters{rps: rps, limiters: make(map[string]*rate.Limiter)}
+}
+
+// forHost returns the limiter for host, creating it on first use. It
+// returns nil if per-host limiting is disabled.
+func (h *hostLimiters) forHost(host string) *rate.Limiter {
+	if h.rps <= 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if l, ok := h.limiters[host]; ok {
+		return l
+	}
+	l := rate.NewLimiter(rate.Limit(h.rps), 1)
+	h.limiters[host] = l
+	return l
+}
@@ -1,25 +1,28 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"time"
 )
 
 // A brief description of program usage
 const usage = `
 This program accepts one or more URLs as positional arguments and
 outputs the number of times the specified target word was found
-on each page.
+on each page. With -depth set, it also follows links discovered on
+each fetched page, up to that many hops from the seed URLs.
 
 `
 
 // Parses options passed on the command line.
-func parseCLI() (word string, numWorkers uint, links []string) {
+func parseCLI() (word string, wordlist string, regex string, ignoreCase bool, numWorkers uint, depth uint, sameHost bool, netCfg netOptions, format string, output string, htmlMode string, links []string) {
 	// Set the usage message for the cli parser
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] URL1 [URL2 [URL3 ...]]\n", os.Args[0])
@@ -29,13 +32,56 @@ func parseCLI() (word string, numWorkers uint, links []string) {
 
 	// Setup the flags we're looking for
 	flag.StringVar(&word, "word", "", "The word to search for.")
+	flag.StringVar(&wordlist, "wordlist", "", "Path to a newline-delimited file of words to search for.")
+	flag.StringVar(&regex, "regex", "", "A regular expression to search for.")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "Fold case when matching -word, -wordlist, or -regex.")
 	flag.UintVar(&numWorkers, "workers", 1, "The number of workers to use.")
+	flag.UintVar(&depth, "depth", 0, "How many hops to follow links discovered on fetched pages (0 = only the given URLs).")
+	flag.BoolVar(&sameHost, "same-host", false, "When following links, only follow ones whose host matches the seed URL's host.")
+	flag.DurationVar(&netCfg.delay, "delay", 0, "Fixed delay a worker sleeps between requests it issues.")
+	flag.Float64Var(&netCfg.rps, "rps", 0, "Global cap on requests per second across all workers (0 = unlimited).")
+	flag.Float64Var(&netCfg.perHostRPS, "per-host-rps", 0, "Cap on requests per second to any single host (0 = unlimited).")
+	flag.UintVar(&netCfg.retries, "retries", 0, "Number of retries on 5xx responses or timeouts, with exponential backoff.")
+	flag.DurationVar(&netCfg.timeout, "timeout", 30*time.Second, "Timeout for each HTTP request.")
+	flag.StringVar(&format, "format", "text", "Output format: text, json, ndjson, csv, or tsv.")
+	flag.StringVar(&output, "output", "", "File to write results to (default stdout).")
+	flag.StringVar(&htmlMode, "html", "auto", "HTML-aware tokenization: auto (text/html responses only), on, or off (byte-accurate scanning).")
 
 	// Parse the flags
 	flag.Parse()
 
-	if word == "" {
-		fmt.Fprintf(os.Stderr, "Need a word to process.\n\n")
+	modesSet := 0
+	for _, mode := range []string{word, wordlist, regex} {
+		if mode != "" {
+			modesSet++
+		}
+	}
+	if modesSet != 1 {
+		fmt.Fprintf(os.Stderr, "Need exactly one of -word, -wordlist, or -regex.\n\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if regex != "" {
+		if _, err := regexp.Compile(regex); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -regex: %v\n\n", err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	switch format {
+	case "text", "json", "ndjson", "csv", "tsv":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown -format %q.\n\n", format)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	switch htmlMode {
+	case "auto", "on", "off":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown -html mode %q.\n\n", htmlMode)
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -57,59 +103,30 @@ func parseCLI() (word string, numWorkers uint, links []string) {
 }
 
 // A Result represents the outcome from counting the occurrence of a
-// word on a web page
+// word (or, in wordlist mode, several words) on a web page.
 type Result struct {
-	link  string // the path to the page that was inspected
-	count uint   // the number of occurrences of the word
-	err   error  // an encountered error, if there was one (otherwise nil)
+	Link   string          `json:"link"`             // the path to the page that was inspected
+	Count  uint            `json:"count,omitempty"`  // the number of occurrences of the word (or matches of the -regex pattern), outside wordlist mode
+	Counts map[string]uint `json:"counts,omitempty"` // per-word occurrence counts, in wordlist mode (nil otherwise)
+	Err    error           `json:"-"`                // an encountered error, if there was one (otherwise nil)
 }
 
-// Formats a Result as a string.
-func (r Result) String() string {
-	return fmt.Sprintf("%s\n\tcount: %d\n\terror: %v", r.link, r.count, r.err)
-}
-
-// countOccurrences counts the number of occurrences of `word` in `s`.
-func countOccurrences(word string, s io.Reader) (uint, error) {
-	// Make a scanner from the s io.Reader, and split by words.
-	scanner := bufio.NewScanner(s)
-	scanner.Split(bufio.ScanWords)
-
-	var count uint = 0
-
-	for scanner.Scan() {
-		// Use scanner.Text() to get the current word.
-		// Increment count if the word matches.
-		if word == scanner.Text() {
-			count++
-		}
-	}
-
-	// Return error if there is one.
-	if err := scanner.Err(); err != nil {
-		return count, err
-	}
-	return count, nil
+// resultJSON mirrors Result for JSON encoding, since error isn't
+// directly marshalable.
+type resultJSON struct {
+	Link   string          `json:"link"`
+	Count  uint            `json:"count,omitempty"`
+	Counts map[string]uint `json:"counts,omitempty"`
+	Err    string          `json:"error,omitempty"`
 }
 
-// wordsOnPage reads links from the `links` channel searching for
-// occurrences of `word` and sending Results over the `results` channel.
-func wordsOnPage(word string, links chan string, results chan Result) {
-	// Loop, receiving from links until it is closed.
-	for link := range links {
-		// Get the link.
-		res, err := http.Get(link)
-
-		// Send result with error if there was one.
-		if err != nil {
-			results <- Result{link, 0, err}
-		} else if res.StatusCode != 200 {
-			results <- Result{link, 0, errors.New("Did not receive 200 OK")}
-		} else {
-			count, err := countOccurrences(word, res.Body)
-			results <- Result{link, count, err}
-		}
+// MarshalJSON implements json.Marshaler.
+func (r Result) MarshalJSON() ([]byte, error) {
+	j := resultJSON{Link: r.Link, Count: r.Count, Counts: r.Counts}
+	if r.Err != nil {
+		j.Err = r.Err.Error()
 	}
+	return json.Marshal(j)
 }
 
 //Parses CLI args. Spins up workers (goroutines) as specified by the
@@ -118,28 +135,75 @@ func wordsOnPage(word string, links chan string, results chan Result) {
 //from goroutines.
 func main() {
 	// Parse options
-	word, numWorkers, links := parseCLI()
-
-	// Make the channels for sending/receiving.
-	link_chan := make(chan string, len(links))
-	result_chan := make(chan Result, len(links))
-
-	// For the number of workers... spin up go routines
-	for i := 0; i < int(numWorkers); i++ {
-		go wordsOnPage(word, link_chan, result_chan)
+	word, wordlistPath, regex, ignoreCase, numWorkers, depth, sameHost, netCfg, format, output, htmlMode, links := parseCLI()
+
+	var wordlist map[string]uint
+	var matcher Matcher
+	switch {
+	case wordlistPath != "":
+		var err error
+		wordlist, err = loadWordlist(wordlistPath, ignoreCase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read wordlist: %v\n", err)
+			os.Exit(1)
+		}
+	case regex != "":
+		pattern := regex
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		matcher = regexMatcher{regexp.MustCompile(pattern)}
+	default:
+		matcher = wordMatcher{word, ignoreCase}
 	}
 
-	// Send the links for processing.
-	for _, link := range links {
-		link_chan <- link
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open -output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	writer, err := newResultWriter(format, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 
-	// Close link channel because we are done sending links.
-	close(link_chan)
+	// Make the channels for sending/receiving. Workers both consume from
+	// and produce into link_chan as they discover new links, so it can't
+	// simply be closed after seeding; the crawler's WaitGroup tracks
+	// in-flight work and closes it for us once there is none left.
+	link_chan := make(chan crawlJob)
+	result_chan := make(chan Result)
 
-	// Receive results
-	for i := 0; i < len(links); i++ {
-		fmt.Println(<-result_chan)
+	cfg := newFetchConfig(netCfg.timeout, netCfg.delay, netCfg.retries, netCfg.rps, netCfg.perHostRPS)
+	c := newCrawler(context.Background(), cfg, matcher, wordlist, ignoreCase, htmlMode, depth, sameHost)
+	for _, link := range links {
+		if c.markVisited(link) {
+			continue
+		}
+		host := ""
+		if seedURL, err := url.Parse(link); err == nil {
+			host = seedURL.Host
+		}
+		c.submit(link_chan, crawlJob{link, 0, host})
+	}
+	c.run(link_chan, result_chan, numWorkers)
+
+	// Receive results until the crawler closes result_chan, writing each
+	// one out as it arrives.
+	for result := range result_chan {
+		if err := writer.Write(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write result: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not finish writing results: %v\n", err)
+		os.Exit(1)
 	}
-
 }
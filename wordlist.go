@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// loadWordlist reads a newline-delimited list of target words from path,
+// returning a map of each word to a zero starting count. If ignoreCase is
+// set, words are folded to lowercase so that countOccurrencesMulti can
+// fold scanned tokens the same way.
+func loadWordlist(path string, ignoreCase bool) (map[string]uint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words := make(map[string]uint)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			continue
+		}
+		if ignoreCase {
+			word = strings.ToLower(word)
+		}
+		words[word] = 0
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// countOccurrencesMulti scans s once, counting occurrences of every word
+// in words so that checking N target words costs one pass rather than N.
+// If ignoreCase is set, each scanned token is folded to lowercase before
+// lookup, so words must already be lowercased (loadWordlist does this).
+func countOccurrencesMulti(words map[string]uint, s io.Reader, ignoreCase bool) (map[string]uint, error) {
+	counts := make(map[string]uint, len(words))
+	for word := range words {
+		counts[word] = 0
+	}
+
+	scanner := bufio.NewScanner(s)
+	scanner.Split(bufio.ScanWords)
+
+	for scanner.Scan() {
+		token := scanner.Text()
+		if ignoreCase {
+			token = strings.ToLower(token)
+		}
+		if _, ok := counts[token]; ok {
+			counts[token]++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return counts, err
+	}
+	return counts, nil
+}